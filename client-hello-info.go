@@ -0,0 +1,356 @@
+// Copyright 2017 Tom Thorogood. All rights reserved.
+// Use of this source code is governed by a
+// Modified BSD License that can be found in
+// the LICENSE file.
+
+package tlsdebug
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// TLS extension numbers, as assigned by IANA.
+//
+// XXX: keep in sync with crypto/tls.
+const (
+	extServerName          uint16 = 0
+	extSupportedGroups     uint16 = 10
+	extECPointFormats      uint16 = 11
+	extSignatureAlgorithms uint16 = 13
+	extALPN                uint16 = 16
+	extSupportedVersions   uint16 = 43
+	extPSKKeyExchangeModes uint16 = 45
+	extKeyShare            uint16 = 51
+)
+
+// ClientHelloInfo is a structured view of a TLS ClientHello
+// message, as produced by parseClientHelloInfo.
+//
+// Every slice field preserves the order the values were
+// sent on the wire, including any GREASE values (RFC 8701).
+type ClientHelloInfo struct {
+	// Version is the legacy client_version field.
+	Version uint16
+
+	// Random is the 32 byte random value sent by the client.
+	Random []byte
+
+	// SessionID is the legacy session_id field.
+	SessionID []byte
+
+	// CipherSuites is the list of cipher suites offered by
+	// the client.
+	CipherSuites []uint16
+
+	// CompressionMethods is the list of legacy compression
+	// methods offered by the client.
+	CompressionMethods []uint8
+
+	// Extensions is the ordered list of extension types
+	// present in the ClientHello, as sent on the wire.
+	Extensions []uint16
+
+	// ServerName is the value of the server_name extension,
+	// or the empty string if it was not sent.
+	ServerName string
+
+	// SupportedVersions is the supported_versions extension,
+	// or nil if it was not sent.
+	SupportedVersions []uint16
+
+	// SupportedGroups is the supported_groups extension
+	// (formerly elliptic_curves), or nil if it was not sent.
+	SupportedGroups []uint16
+
+	// EllipticCurvePointFormats is the ec_point_formats
+	// extension, or nil if it was not sent.
+	EllipticCurvePointFormats []uint8
+
+	// KeyShareGroups is the list of groups for which the
+	// client sent a key_share entry in the key_share
+	// extension, or nil if it was not sent.
+	KeyShareGroups []uint16
+
+	// SignatureSchemes is the signature_algorithms extension,
+	// or nil if it was not sent.
+	SignatureSchemes []uint16
+
+	// ALPNProtocols is the
+	// application_layer_protocol_negotiation extension, or
+	// nil if it was not sent.
+	ALPNProtocols []string
+
+	// PSKKeyExchangeModes is the psk_key_exchange_modes
+	// extension, or nil if it was not sent.
+	PSKKeyExchangeModes []uint8
+}
+
+// byteReader is a small cryptobyte-style cursor over a byte
+// slice. It is used to parse the length-prefixed structures
+// that make up a ClientHello message without copying.
+type byteReader struct {
+	data []byte
+}
+
+func (r *byteReader) empty() bool {
+	return len(r.data) == 0
+}
+
+func (r *byteReader) readUint8() (v uint8, ok bool) {
+	if len(r.data) < 1 {
+		return 0, false
+	}
+
+	v, r.data = r.data[0], r.data[1:]
+	return v, true
+}
+
+func (r *byteReader) readUint16() (v uint16, ok bool) {
+	if len(r.data) < 2 {
+		return 0, false
+	}
+
+	v, r.data = binary.BigEndian.Uint16(r.data), r.data[2:]
+	return v, true
+}
+
+func (r *byteReader) readBytes(n int) (v []byte, ok bool) {
+	if len(r.data) < n {
+		return nil, false
+	}
+
+	v, r.data = r.data[:n], r.data[n:]
+	return v, true
+}
+
+// readUint8LengthPrefixed reads a uint8 length prefix
+// followed by that many bytes, returning them as a child
+// byteReader.
+func (r *byteReader) readUint8LengthPrefixed() (*byteReader, bool) {
+	n, ok := r.readUint8()
+	if !ok {
+		return nil, false
+	}
+
+	b, ok := r.readBytes(int(n))
+	if !ok {
+		return nil, false
+	}
+
+	return &byteReader{b}, true
+}
+
+// readUint16LengthPrefixed reads a uint16 length prefix
+// followed by that many bytes, returning them as a child
+// byteReader.
+func (r *byteReader) readUint16LengthPrefixed() (*byteReader, bool) {
+	n, ok := r.readUint16()
+	if !ok {
+		return nil, false
+	}
+
+	b, ok := r.readBytes(int(n))
+	if !ok {
+		return nil, false
+	}
+
+	return &byteReader{b}, true
+}
+
+// parseClientHelloInfo parses b, the body of a ClientHello
+// handshake message as returned by parseHello, into a
+// *ClientHelloInfo.
+func parseClientHelloInfo(b []byte) (*ClientHelloInfo, error) {
+	r := &byteReader{b}
+
+	info := new(ClientHelloInfo)
+
+	var ok bool
+	if info.Version, ok = r.readUint16(); !ok {
+		return nil, errors.New("ClientHello is missing client_version")
+	}
+
+	if info.Random, ok = r.readBytes(32); !ok {
+		return nil, errors.New("ClientHello is missing random")
+	}
+
+	sessionID, ok := r.readUint8LengthPrefixed()
+	if !ok {
+		return nil, errors.New("ClientHello has malformed session_id")
+	}
+	info.SessionID = append([]byte(nil), sessionID.data...)
+
+	cipherSuites, ok := r.readUint16LengthPrefixed()
+	if !ok || cipherSuites.empty() || len(cipherSuites.data)%2 != 0 {
+		return nil, errors.New("ClientHello has malformed cipher_suites")
+	}
+	for !cipherSuites.empty() {
+		suite, _ := cipherSuites.readUint16()
+		info.CipherSuites = append(info.CipherSuites, suite)
+	}
+
+	compressionMethods, ok := r.readUint8LengthPrefixed()
+	if !ok || compressionMethods.empty() {
+		return nil, errors.New("ClientHello has malformed compression_methods")
+	}
+	info.CompressionMethods = append([]uint8(nil), compressionMethods.data...)
+
+	if r.empty() {
+		// Extensions are optional.
+		return info, nil
+	}
+
+	extensions, ok := r.readUint16LengthPrefixed()
+	if !ok || !r.empty() {
+		return nil, errors.New("ClientHello has malformed extensions")
+	}
+
+	for !extensions.empty() {
+		extType, ok := extensions.readUint16()
+		if !ok {
+			return nil, errors.New("ClientHello has malformed extension header")
+		}
+
+		ext, ok := extensions.readUint16LengthPrefixed()
+		if !ok {
+			return nil, errors.New("ClientHello has malformed extension body")
+		}
+
+		info.Extensions = append(info.Extensions, extType)
+
+		if err := parseExtension(info, extType, ext); err != nil {
+			return nil, err
+		}
+	}
+
+	return info, nil
+}
+
+// parseExtension parses a single ClientHello extension,
+// identified by typ with body ext, into info. Unrecognised
+// extensions are recorded in info.Extensions (already done
+// by the caller) and otherwise ignored.
+func parseExtension(info *ClientHelloInfo, typ uint16, ext *byteReader) error {
+	switch typ {
+	case extServerName:
+		names, ok := ext.readUint16LengthPrefixed()
+		if !ok {
+			return errors.New("ClientHello has malformed server_name extension")
+		}
+
+		for !names.empty() {
+			nameType, ok := names.readUint8()
+			if !ok {
+				return errors.New("ClientHello has malformed server_name extension")
+			}
+
+			name, ok := names.readUint16LengthPrefixed()
+			if !ok {
+				return errors.New("ClientHello has malformed server_name extension")
+			}
+
+			const nameTypeHostName = 0
+			if nameType == nameTypeHostName {
+				info.ServerName = string(name.data)
+			}
+		}
+
+	case extSupportedVersions:
+		versions, ok := ext.readUint8LengthPrefixed()
+		if !ok || versions.empty() || len(versions.data)%2 != 0 {
+			return errors.New("ClientHello has malformed supported_versions extension")
+		}
+		for !versions.empty() {
+			v, _ := versions.readUint16()
+			info.SupportedVersions = append(info.SupportedVersions, v)
+		}
+
+	case extSupportedGroups:
+		groups, ok := ext.readUint16LengthPrefixed()
+		if !ok || groups.empty() || len(groups.data)%2 != 0 {
+			return errors.New("ClientHello has malformed supported_groups extension")
+		}
+		for !groups.empty() {
+			g, _ := groups.readUint16()
+			info.SupportedGroups = append(info.SupportedGroups, g)
+		}
+
+	case extECPointFormats:
+		formats, ok := ext.readUint8LengthPrefixed()
+		if !ok || formats.empty() {
+			return errors.New("ClientHello has malformed ec_point_formats extension")
+		}
+		info.EllipticCurvePointFormats = append([]uint8(nil), formats.data...)
+
+	case extSignatureAlgorithms:
+		schemes, ok := ext.readUint16LengthPrefixed()
+		if !ok || schemes.empty() || len(schemes.data)%2 != 0 {
+			return errors.New("ClientHello has malformed signature_algorithms extension")
+		}
+		for !schemes.empty() {
+			s, _ := schemes.readUint16()
+			info.SignatureSchemes = append(info.SignatureSchemes, s)
+		}
+
+	case extALPN:
+		protos, ok := ext.readUint16LengthPrefixed()
+		if !ok || protos.empty() {
+			return errors.New("ClientHello has malformed ALPN extension")
+		}
+		for !protos.empty() {
+			proto, ok := protos.readUint8LengthPrefixed()
+			if !ok {
+				return errors.New("ClientHello has malformed ALPN extension")
+			}
+			info.ALPNProtocols = append(info.ALPNProtocols, string(proto.data))
+		}
+
+	case extPSKKeyExchangeModes:
+		modes, ok := ext.readUint8LengthPrefixed()
+		if !ok || modes.empty() {
+			return errors.New("ClientHello has malformed psk_key_exchange_modes extension")
+		}
+		info.PSKKeyExchangeModes = append([]uint8(nil), modes.data...)
+
+	case extKeyShare:
+		shares, ok := ext.readUint16LengthPrefixed()
+		if !ok {
+			return errors.New("ClientHello has malformed key_share extension")
+		}
+		for !shares.empty() {
+			group, ok := shares.readUint16()
+			if !ok {
+				return errors.New("ClientHello has malformed key_share extension")
+			}
+			keyExchange, ok := shares.readUint16LengthPrefixed()
+			if !ok {
+				return errors.New("ClientHello has malformed key_share extension")
+			}
+			_ = keyExchange
+
+			info.KeyShareGroups = append(info.KeyShareGroups, group)
+		}
+	}
+
+	return nil
+}
+
+// isGREASEUint16 reports whether v is one of the reserved
+// GREASE values defined by RFC 8701 (0x0a0a, 0x1a1a, ...,
+// 0xfafa).
+func isGREASEUint16(v uint16) bool {
+	return v&0x0f0f == 0x0a0a && v>>8 == v&0xff
+}
+
+// stripGREASEUint16 returns a copy of vs with any GREASE
+// values removed.
+func stripGREASEUint16(vs []uint16) []uint16 {
+	out := make([]uint16, 0, len(vs))
+	for _, v := range vs {
+		if !isGREASEUint16(v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,168 @@
+// Copyright 2017 Tom Thorogood. All rights reserved.
+// Use of this source code is governed by a
+// Modified BSD License that can be found in
+// the LICENSE file.
+
+package tlsdebug
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// buildTestClientHello returns the body of a synthetic
+// ClientHello handshake message (as parseHello would return
+// it) with the given session_id, exercising every extension
+// parseClientHelloInfo understands plus a GREASE cipher
+// suite, extension and supported group (RFC 8701).
+func buildTestClientHello(sessionID []byte) []byte {
+	u16 := func(v uint16) []byte {
+		b := make([]byte, 2)
+		binary.BigEndian.PutUint16(b, v)
+		return b
+	}
+
+	u16List := func(vs ...uint16) []byte {
+		var b bytes.Buffer
+		for _, v := range vs {
+			b.Write(u16(v))
+		}
+		return b.Bytes()
+	}
+
+	u8LengthPrefixed := func(data []byte) []byte {
+		return append([]byte{byte(len(data))}, data...)
+	}
+
+	u16LengthPrefixed := func(data []byte) []byte {
+		return append(u16(uint16(len(data))), data...)
+	}
+
+	extension := func(typ uint16, body []byte) []byte {
+		return append(u16(typ), u16LengthPrefixed(body)...)
+	}
+
+	serverNameEntry := append([]byte{0}, u16LengthPrefixed([]byte("example.com"))...)
+	serverNameExt := u16LengthPrefixed(serverNameEntry)
+
+	groupsExt := u16LengthPrefixed(u16List(0x0a0a /* GREASE */, 0x001d, 0x0017))
+
+	ecPointFormatsExt := u8LengthPrefixed([]byte{0})
+
+	alpnEntries := append(u8LengthPrefixed([]byte("h2")), u8LengthPrefixed([]byte("http/1.1"))...)
+	alpnExt := u16LengthPrefixed(alpnEntries)
+
+	supportedVersionsExt := u8LengthPrefixed(u16List(0x0a0a /* GREASE */, 0x0304, 0x0303))
+
+	sigAlgsExt := u16LengthPrefixed(u16List(0x0403, 0x0804))
+
+	pskModesExt := u8LengthPrefixed([]byte{1})
+
+	keyShareEntry := append(u16(0x001d), u16LengthPrefixed([]byte{0xab, 0xcd})...)
+	keyShareExt := u16LengthPrefixed(keyShareEntry)
+
+	var extensions bytes.Buffer
+	extensions.Write(extension(0x0a0a, nil)) // GREASE
+	extensions.Write(extension(extServerName, serverNameExt))
+	extensions.Write(extension(extSupportedGroups, groupsExt))
+	extensions.Write(extension(extECPointFormats, ecPointFormatsExt))
+	extensions.Write(extension(extALPN, alpnExt))
+	extensions.Write(extension(extSupportedVersions, supportedVersionsExt))
+	extensions.Write(extension(extSignatureAlgorithms, sigAlgsExt))
+	extensions.Write(extension(extPSKKeyExchangeModes, pskModesExt))
+	extensions.Write(extension(extKeyShare, keyShareExt))
+
+	var b bytes.Buffer
+	b.Write(u16(0x0303))                                                             // client_version: TLS 1.2
+	b.Write(make([]byte, 32))                                                        // random
+	b.Write(u8LengthPrefixed(sessionID))                                             // session_id
+	b.Write(u16LengthPrefixed(u16List(0x0a0a /* GREASE */, 0xc02b, 0xc02f, 0x009c))) // cipher_suites
+	b.Write(u8LengthPrefixed([]byte{0}))                                             // compression_methods: null
+	b.Write(u16LengthPrefixed(extensions.Bytes()))
+	return b.Bytes()
+}
+
+func TestParseClientHelloInfo(t *testing.T) {
+	info, err := parseClientHelloInfo(buildTestClientHello(nil))
+	if err != nil {
+		t.Fatalf("parseClientHelloInfo: %v", err)
+	}
+
+	if info.Version != 0x0303 {
+		t.Errorf("Version = %#x, want 0x0303", info.Version)
+	}
+
+	if len(info.SessionID) != 0 {
+		t.Errorf("SessionID = %x, want empty", info.SessionID)
+	}
+
+	wantCiphers := []uint16{0x0a0a, 0xc02b, 0xc02f, 0x009c}
+	if !reflect.DeepEqual(info.CipherSuites, wantCiphers) {
+		t.Errorf("CipherSuites = %#x, want %#x", info.CipherSuites, wantCiphers)
+	}
+
+	wantExtensions := []uint16{0x0a0a, extServerName, extSupportedGroups, extECPointFormats,
+		extALPN, extSupportedVersions, extSignatureAlgorithms, extPSKKeyExchangeModes, extKeyShare}
+	if !reflect.DeepEqual(info.Extensions, wantExtensions) {
+		t.Errorf("Extensions = %#x, want %#x", info.Extensions, wantExtensions)
+	}
+
+	if info.ServerName != "example.com" {
+		t.Errorf("ServerName = %q, want %q", info.ServerName, "example.com")
+	}
+
+	wantSupportedVersions := []uint16{0x0a0a, 0x0304, 0x0303}
+	if !reflect.DeepEqual(info.SupportedVersions, wantSupportedVersions) {
+		t.Errorf("SupportedVersions = %#x, want %#x", info.SupportedVersions, wantSupportedVersions)
+	}
+
+	wantGroups := []uint16{0x0a0a, 0x001d, 0x0017}
+	if !reflect.DeepEqual(info.SupportedGroups, wantGroups) {
+		t.Errorf("SupportedGroups = %#x, want %#x", info.SupportedGroups, wantGroups)
+	}
+
+	wantALPN := []string{"h2", "http/1.1"}
+	if !reflect.DeepEqual(info.ALPNProtocols, wantALPN) {
+		t.Errorf("ALPNProtocols = %q, want %q", info.ALPNProtocols, wantALPN)
+	}
+
+	wantKeyShareGroups := []uint16{0x001d}
+	if !reflect.DeepEqual(info.KeyShareGroups, wantKeyShareGroups) {
+		t.Errorf("KeyShareGroups = %#x, want %#x", info.KeyShareGroups, wantKeyShareGroups)
+	}
+
+	wantSigSchemes := []uint16{0x0403, 0x0804}
+	if !reflect.DeepEqual(info.SignatureSchemes, wantSigSchemes) {
+		t.Errorf("SignatureSchemes = %#x, want %#x", info.SignatureSchemes, wantSigSchemes)
+	}
+
+	wantPSKModes := []uint8{1}
+	if !reflect.DeepEqual(info.PSKKeyExchangeModes, wantPSKModes) {
+		t.Errorf("PSKKeyExchangeModes = %v, want %v", info.PSKKeyExchangeModes, wantPSKModes)
+	}
+}
+
+// TestParseClientHelloInfoSessionIDCopied guards against
+// info.SessionID aliasing the byte slice passed to
+// parseClientHelloInfo, which would let later mutation of a
+// reused read buffer corrupt an already parsed
+// *ClientHelloInfo.
+func TestParseClientHelloInfoSessionIDCopied(t *testing.T) {
+	raw := buildTestClientHello([]byte{1, 2, 3, 4})
+
+	info, err := parseClientHelloInfo(raw)
+	if err != nil {
+		t.Fatalf("parseClientHelloInfo: %v", err)
+	}
+
+	want := []byte{1, 2, 3, 4}
+	for i := range raw {
+		raw[i] = 0xff
+	}
+
+	if !bytes.Equal(info.SessionID, want) {
+		t.Fatalf("SessionID = %x, want %x (mutating raw should not affect it)", info.SessionID, want)
+	}
+}
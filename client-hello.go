@@ -25,14 +25,17 @@ var bufferPool = &sync.Pool{
 // extracting the ClientHello message.
 //
 // conn is the underlying net.Conn that the ClientHello was
-// extracted from. ch is the ClientHello message. err is
-// any error that occurred while extracting the ClientHello.
+// extracted from. ch is the ClientHello message. info is a
+// structured view of ch, or nil if ch could not be parsed.
+// err is any error that occurred while extracting the
+// ClientHello.
 //
 // conn MUST NOT be written to or read from, doing so will
-// cause the connection to fail. ch MUST NOT be modified,
-// doing so MAY cause the connection to fail. ch MUST NOT be
-// retained after the function returns as it will be reused.
-type ClientHelloFunc func(conn net.Conn, ch []byte, err error)
+// cause the connection to fail. ch and info MUST NOT be
+// modified, doing so MAY cause the connection to fail. ch
+// and info MUST NOT be retained after the function returns
+// as they will be reused.
+type ClientHelloFunc func(conn net.Conn, ch []byte, info *ClientHelloInfo, err error)
 
 // ClientHelloListener wraps a net.Listener and wraps each
 // accepted net.Conn with ClientHelloConn.
@@ -115,11 +118,15 @@ func (ch *clientHelloConn) Read(b []byte) (n int, err error) {
 
 	ch.doneCH = true
 
+	var info *ClientHelloInfo
 	if herr == nil {
 		hb, herr = parseHello(hb)
 	}
+	if herr == nil {
+		info, herr = parseClientHelloInfo(hb)
+	}
 
-	ch.fn(ch.Conn, hb, herr)
+	ch.fn(ch.Conn, hb, info, herr)
 
 	if ch.buf != nil {
 		ch.buf.Reset()
@@ -0,0 +1,25 @@
+// Copyright 2017 Tom Thorogood. All rights reserved.
+// Use of this source code is governed by a
+// Modified BSD License that can be found in
+// the LICENSE file.
+
+package tlsdebug
+
+import "crypto/tls"
+
+var curveNames = map[tls.CurveID]string{
+	// XXX: keep in sync with crypto/tls.
+	tls.CurveP256: "P-256",
+	tls.CurveP384: "P-384",
+	tls.CurveP521: "P-521",
+	tls.X25519:    "X25519",
+}
+
+// CurveName returns a human readable name for a TLS key
+// exchange curve, as negotiated during the handshake and
+// reported in crypto/tls.ConnectionState.
+//
+// It returns an empty string if the curve is unknown.
+func CurveName(curve tls.CurveID) string {
+	return curveNames[curve]
+}
@@ -0,0 +1,47 @@
+// Copyright 2017 Tom Thorogood. All rights reserved.
+// Use of this source code is governed by a
+// Modified BSD License that can be found in
+// the LICENSE file.
+
+package tlsdebug
+
+import (
+	"crypto/md5"
+	"strconv"
+	"strings"
+)
+
+// JA3 computes the JA3 TLS client fingerprint of ch, as
+// described by Salesforce's JA3 specification.
+//
+// It returns the JA3 string, of the form
+// "Version,Ciphers,Extensions,EllipticCurves,EllipticCurvePointFormats",
+// and its MD5 digest. GREASE values (RFC 8701) are excluded
+// from the Ciphers, Extensions and EllipticCurves fields.
+func JA3(ch *ClientHelloInfo) (string, [16]byte) {
+	s := strings.Join([]string{
+		strconv.Itoa(int(ch.Version)),
+		joinUint16(stripGREASEUint16(ch.CipherSuites)),
+		joinUint16(stripGREASEUint16(ch.Extensions)),
+		joinUint16(stripGREASEUint16(ch.SupportedGroups)),
+		joinUint8(ch.EllipticCurvePointFormats),
+	}, ",")
+
+	return s, md5.Sum([]byte(s))
+}
+
+func joinUint16(vs []uint16) string {
+	ss := make([]string, len(vs))
+	for i, v := range vs {
+		ss[i] = strconv.Itoa(int(v))
+	}
+	return strings.Join(ss, "-")
+}
+
+func joinUint8(vs []uint8) string {
+	ss := make([]string, len(vs))
+	for i, v := range vs {
+		ss[i] = strconv.Itoa(int(v))
+	}
+	return strings.Join(ss, "-")
+}
@@ -0,0 +1,30 @@
+// Copyright 2017 Tom Thorogood. All rights reserved.
+// Use of this source code is governed by a
+// Modified BSD License that can be found in
+// the LICENSE file.
+
+package tlsdebug
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestJA3(t *testing.T) {
+	info, err := parseClientHelloInfo(buildTestClientHello(nil))
+	if err != nil {
+		t.Fatalf("parseClientHelloInfo: %v", err)
+	}
+
+	const wantString = "771,49195-49199-156,0-10-11-16-43-13-45-51,29-23,0"
+	const wantMD5Hex = "755d2f3bf63047565dbe473f229f3d53"
+
+	s, sum := JA3(info)
+	if s != wantString {
+		t.Errorf("JA3 string = %q, want %q", s, wantString)
+	}
+
+	if gotHex := hex.EncodeToString(sum[:]); gotHex != wantMD5Hex {
+		t.Errorf("JA3 MD5 = %s, want %s", gotHex, wantMD5Hex)
+	}
+}
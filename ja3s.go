@@ -0,0 +1,29 @@
+// Copyright 2017 Tom Thorogood. All rights reserved.
+// Use of this source code is governed by a
+// Modified BSD License that can be found in
+// the LICENSE file.
+
+package tlsdebug
+
+import (
+	"crypto/md5"
+	"strconv"
+	"strings"
+)
+
+// JA3S computes the JA3S TLS server fingerprint of sh, the
+// server's counterpart to JA3.
+//
+// It returns the JA3S string, of the form
+// "Version,Cipher,Extensions", and its MD5 digest. See the
+// ServerHelloInfo doc comment for the circumstances in which
+// Extensions may be empty.
+func JA3S(sh *ServerHelloInfo) (string, [16]byte) {
+	s := strings.Join([]string{
+		strconv.Itoa(int(sh.Version)),
+		strconv.Itoa(int(sh.CipherSuite)),
+		joinUint16(sh.Extensions),
+	}, ",")
+
+	return s, md5.Sum([]byte(s))
+}
@@ -0,0 +1,31 @@
+// Copyright 2017 Tom Thorogood. All rights reserved.
+// Use of this source code is governed by a
+// Modified BSD License that can be found in
+// the LICENSE file.
+
+package tlsdebug
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestJA3S(t *testing.T) {
+	sh := &ServerHelloInfo{
+		Version:     0x0304, // TLS 1.3
+		CipherSuite: 0x1301, // TLS_AES_128_GCM_SHA256
+		Extensions:  []uint16{51, 43},
+	}
+
+	const wantString = "772,4865,51-43"
+	const wantMD5Hex = "134c270d52dd3495d39878f76f646581"
+
+	s, sum := JA3S(sh)
+	if s != wantString {
+		t.Errorf("JA3S string = %q, want %q", s, wantString)
+	}
+
+	if gotHex := hex.EncodeToString(sum[:]); gotHex != wantMD5Hex {
+		t.Errorf("JA3S MD5 = %s, want %s", gotHex, wantMD5Hex)
+	}
+}
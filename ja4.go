@@ -0,0 +1,136 @@
+// Copyright 2017 Tom Thorogood. All rights reserved.
+// Use of this source code is governed by a
+// Modified BSD License that can be found in
+// the LICENSE file.
+
+package tlsdebug
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+)
+
+// JA4 computes the JA4 TLS client fingerprint of ch, as
+// described by FoxIO's JA4 specification.
+//
+// GREASE values (RFC 8701) are stripped from every list
+// before counting, sorting or hashing, as required by the
+// JA4 specification.
+func JA4(ch *ClientHelloInfo) string {
+	sni := byte('i')
+	if ch.ServerName != "" {
+		sni = 'd'
+	}
+
+	ciphers := stripGREASEUint16(ch.CipherSuites)
+	extensions := stripGREASEUint16(ch.Extensions)
+
+	alpn := ja4ALPN(ch.ALPNProtocols)
+
+	sortedCiphers := append([]uint16(nil), ciphers...)
+	sort.Slice(sortedCiphers, func(i, j int) bool { return sortedCiphers[i] < sortedCiphers[j] })
+	ciphersHash := sha256.Sum256([]byte(joinHexUint16(sortedCiphers)))
+
+	sortedExtensions := make([]uint16, 0, len(extensions))
+	for _, e := range extensions {
+		// server_name and ALPN are excluded from the
+		// extension hash by the JA4 specification, as
+		// they are already represented elsewhere in the
+		// fingerprint.
+		if e == extServerName || e == extALPN {
+			continue
+		}
+		sortedExtensions = append(sortedExtensions, e)
+	}
+	sort.Slice(sortedExtensions, func(i, j int) bool { return sortedExtensions[i] < sortedExtensions[j] })
+
+	sigSchemes := stripGREASEUint16(ch.SignatureSchemes)
+
+	extensionsInput := joinHexUint16(sortedExtensions)
+	if len(sigSchemes) > 0 {
+		extensionsInput += "_" + joinHexUint16(sigSchemes)
+	}
+	extensionsHash := sha256.Sum256([]byte(extensionsInput))
+
+	return fmt.Sprintf("t%s%c%02d%02d%s_%s_%s",
+		ja4Version(ch),
+		sni,
+		clampJA4Count(len(ciphers)),
+		clampJA4Count(len(extensions)),
+		alpn,
+		hex.EncodeToString(ciphersHash[:])[:12],
+		hex.EncodeToString(extensionsHash[:])[:12],
+	)
+}
+
+// ja4Version returns the two character JA4 version code for
+// the highest TLS version offered by ch, preferring the
+// supported_versions extension over the legacy
+// client_version field.
+func ja4Version(ch *ClientHelloInfo) string {
+	vers := ch.Version
+	for _, v := range ch.SupportedVersions {
+		if !isGREASEUint16(v) && v > vers {
+			vers = v
+		}
+	}
+
+	return ja4VersionCode(vers)
+}
+
+// ja4VersionCode returns the two character JA4 version code
+// for a single TLS version number.
+func ja4VersionCode(vers uint16) string {
+	switch vers {
+	case tls_VersionTLS13:
+		return "13"
+	case 0x0303:
+		return "12"
+	case 0x0302:
+		return "11"
+	case 0x0301:
+		return "10"
+	case 0x0300:
+		return "s3"
+	default:
+		return "00"
+	}
+}
+
+// ja4ALPN returns the two character JA4 ALPN code: the first
+// and last byte of the first offered ALPN protocol, or "00"
+// if none was offered.
+func ja4ALPN(protos []string) string {
+	if len(protos) == 0 {
+		return "00"
+	}
+
+	p := protos[0]
+	if len(p) == 0 {
+		return "00"
+	}
+
+	return string([]byte{p[0], p[len(p)-1]})
+}
+
+// clampJA4Count clamps n to the two decimal digits used by
+// the JA4 specification's cipher and extension counts.
+func clampJA4Count(n int) int {
+	if n > 99 {
+		return 99
+	}
+	return n
+}
+
+func joinHexUint16(vs []uint16) string {
+	b := make([]byte, 0, len(vs)*5)
+	for i, v := range vs {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = append(b, fmt.Sprintf("%04x", v)...)
+	}
+	return string(b)
+}
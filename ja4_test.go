@@ -0,0 +1,21 @@
+// Copyright 2017 Tom Thorogood. All rights reserved.
+// Use of this source code is governed by a
+// Modified BSD License that can be found in
+// the LICENSE file.
+
+package tlsdebug
+
+import "testing"
+
+func TestJA4(t *testing.T) {
+	info, err := parseClientHelloInfo(buildTestClientHello(nil))
+	if err != nil {
+		t.Fatalf("parseClientHelloInfo: %v", err)
+	}
+
+	const want = "t13d0308h2_b80a1783d7a2_33ac81084287"
+
+	if got := JA4(info); got != want {
+		t.Errorf("JA4 = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,36 @@
+// Copyright 2017 Tom Thorogood. All rights reserved.
+// Use of this source code is governed by a
+// Modified BSD License that can be found in
+// the LICENSE file.
+
+package tlsdebug
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// JA4S computes the JA4S TLS server fingerprint of sh, as
+// described by FoxIO's JA4 specification.
+//
+// JA4S has no SNI component, unlike JA4, since the server
+// does not echo the SNI it was sent. See the ServerHelloInfo
+// doc comment for the circumstances in which Extensions may
+// be empty, which JA4S folds into its extensions hash.
+func JA4S(sh *ServerHelloInfo, alpn string) string {
+	a := "00"
+	if alpn != "" {
+		a = string([]byte{alpn[0], alpn[len(alpn)-1]})
+	}
+
+	extensionsHash := sha256.Sum256([]byte(joinHexUint16(sh.Extensions)))
+
+	return fmt.Sprintf("t%s%02d%s_%04x_%s",
+		ja4VersionCode(sh.Version),
+		clampJA4Count(len(sh.Extensions)),
+		a,
+		sh.CipherSuite,
+		hex.EncodeToString(extensionsHash[:])[:12],
+	)
+}
@@ -0,0 +1,22 @@
+// Copyright 2017 Tom Thorogood. All rights reserved.
+// Use of this source code is governed by a
+// Modified BSD License that can be found in
+// the LICENSE file.
+
+package tlsdebug
+
+import "testing"
+
+func TestJA4S(t *testing.T) {
+	sh := &ServerHelloInfo{
+		Version:     0x0304, // TLS 1.3
+		CipherSuite: 0x1301, // TLS_AES_128_GCM_SHA256
+		Extensions:  []uint16{51, 43},
+	}
+
+	const want = "t1302h2_1301_234ea6891581"
+
+	if got := JA4S(sh, "h2"); got != want {
+		t.Errorf("JA4S = %q, want %q", got, want)
+	}
+}
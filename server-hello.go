@@ -0,0 +1,244 @@
+// Copyright 2017 Tom Thorogood. All rights reserved.
+// Use of this source code is governed by a
+// Modified BSD License that can be found in
+// the LICENSE file.
+
+package tlsdebug
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+)
+
+// ServerHelloInfo is a structured view of a TLS ServerHello
+// message, suitable for JA3S/JA4S fingerprinting.
+//
+// IMPORTANT: crypto/tls does not expose the raw ServerHello to
+// calling code. ServerHelloInfoFromConnectionState, the only
+// construction path reachable from a *tls.ConnectionState,
+// cannot populate Extensions at all and always leaves it nil;
+// a JA3S/JA4S computed from that ServerHelloInfo collapses
+// every server configuration down to its version and cipher
+// suite alone. To fingerprint by the server's actual
+// extensions, wrap the client-side net.Conn passed to
+// tls.Client with ServerHelloConn instead, which parses them
+// from the raw ServerHello.
+type ServerHelloInfo struct {
+	// Version is the negotiated TLS version when ServerHelloInfo
+	// is built via ServerHelloInfoFromConnectionState, but the
+	// legacy server_version field of the wire message when built
+	// via ServerHelloConn/parseServerHelloInfo. For a TLS 1.3
+	// ServerHello these differ: server_version is fixed at
+	// 0x0303 (TLS 1.2) by RFC 8446, and the real negotiated
+	// version is only carried in the supported_versions
+	// extension, which is not parsed out separately here.
+	Version uint16
+
+	// CipherSuite is the negotiated cipher suite.
+	CipherSuite uint16
+
+	// Extensions is the ordered list of extension types sent
+	// in the ServerHello. It is always nil when ServerHelloInfo
+	// is built via ServerHelloInfoFromConnectionState; see the
+	// ServerHelloInfo doc comment.
+	Extensions []uint16
+}
+
+// ServerHelloInfoFromConnectionState builds a *ServerHelloInfo
+// from a completed handshake's *tls.ConnectionState. Extensions
+// is always nil; see the ServerHelloInfo doc comment for why,
+// and for an alternative that populates it.
+func ServerHelloInfoFromConnectionState(cs *tls.ConnectionState) *ServerHelloInfo {
+	return &ServerHelloInfo{
+		Version:     cs.Version,
+		CipherSuite: cs.CipherSuite,
+	}
+}
+
+// ServerHelloFunc represents a function to call after
+// extracting the ServerHello message.
+//
+// conn is the underlying net.Conn that the ServerHello was
+// extracted from. sh is the ServerHello message. info is a
+// structured view of sh, or nil if sh could not be parsed.
+// err is any error that occurred while extracting the
+// ServerHello.
+//
+// conn MUST NOT be written to or read from, doing so will
+// cause the connection to fail. sh and info MUST NOT be
+// modified, doing so MAY cause the connection to fail. sh and
+// info MUST NOT be retained after the function returns as
+// they will be reused.
+type ServerHelloFunc func(conn net.Conn, sh []byte, info *ServerHelloInfo, err error)
+
+// ServerHelloConn wraps c, the client-side net.Conn that is
+// about to be passed to tls.Client, extracts the ServerHello
+// from the server's first flight and calls fn. It is
+// transparent to the user of the net.Conn and does nothing
+// after the ServerHello has been extracted.
+//
+// Unlike ServerHelloInfoFromConnectionState, the info passed
+// to fn has its Extensions field populated from the raw
+// ServerHello, because it is parsed from the wire rather than
+// reconstructed from a *tls.ConnectionState.
+func ServerHelloConn(c net.Conn, fn ServerHelloFunc) net.Conn {
+	return &serverHelloConn{Conn: c, fn: fn}
+}
+
+type serverHelloConn struct {
+	net.Conn
+	fn ServerHelloFunc
+
+	buf *bytes.Buffer
+
+	doneSH bool
+}
+
+func (sh *serverHelloConn) Close() error {
+	if sh.buf != nil {
+		sh.buf.Reset()
+		bufferPool.Put(sh.buf)
+		sh.buf = nil
+	}
+
+	return sh.Conn.Close()
+}
+
+func (sh *serverHelloConn) Read(b []byte) (n int, err error) {
+	n, err = sh.Conn.Read(b)
+	if sh.doneSH || (err != nil && err != io.EOF) {
+		return n, err
+	}
+
+	hb := b[:n]
+	if sh.buf != nil {
+		sh.buf.Write(b[:n])
+		hb = sh.buf.Bytes()
+	}
+
+	hb, herr := handshakeRecord(hb)
+	if herr == io.ErrUnexpectedEOF {
+		if sh.buf != nil {
+			// Continue buffering the
+			// handshake and wait.
+			return n, err
+		}
+
+		// The handshake record was not read in
+		// a single call to Read. We buffer what
+		// we have and wait.
+		sh.buf = bufferPool.Get().(*bytes.Buffer)
+		sh.buf.Grow(512 + 32)
+		sh.buf.Write(b[:n])
+		return n, err
+	}
+
+	sh.doneSH = true
+
+	var info *ServerHelloInfo
+	if herr == nil {
+		hb, herr = parseServerHello(hb)
+	}
+	if herr == nil {
+		info, herr = parseServerHelloInfo(hb)
+	}
+
+	sh.fn(sh.Conn, hb, info, herr)
+
+	if sh.buf != nil {
+		sh.buf.Reset()
+		bufferPool.Put(sh.buf)
+		sh.buf = nil
+	}
+
+	return n, err
+}
+
+// Parse a TLS handshake record as a ServerHello message.
+func parseServerHello(b []byte) ([]byte, error) {
+	const headerSize = 1 + 3
+	if len(b) < headerSize {
+		return nil, errors.New("handshake record is too short")
+	}
+
+	typ := b[0]
+
+	var length uint32
+	for _, v := range b[1:4] {
+		length = (length << 8) | uint32(v)
+	}
+
+	b = b[headerSize:]
+
+	const typeServerHello = 2
+	if typ != typeServerHello {
+		return nil, fmt.Errorf("handshake record (%d) is not ServerHello", typ)
+	}
+
+	if int(length) > len(b) {
+		return nil, errors.New("handshake record has invalid length")
+	}
+
+	return b[:length], nil
+}
+
+// parseServerHelloInfo parses b, the body of a ServerHello
+// handshake message as returned by parseServerHello, into a
+// *ServerHelloInfo.
+func parseServerHelloInfo(b []byte) (*ServerHelloInfo, error) {
+	r := &byteReader{b}
+
+	info := new(ServerHelloInfo)
+
+	var ok bool
+	if info.Version, ok = r.readUint16(); !ok {
+		return nil, errors.New("ServerHello is missing server_version")
+	}
+
+	if _, ok = r.readBytes(32); !ok {
+		return nil, errors.New("ServerHello is missing random")
+	}
+
+	if _, ok = r.readUint8LengthPrefixed(); !ok {
+		return nil, errors.New("ServerHello has malformed session_id")
+	}
+
+	if info.CipherSuite, ok = r.readUint16(); !ok {
+		return nil, errors.New("ServerHello is missing cipher_suite")
+	}
+
+	if _, ok = r.readUint8(); !ok {
+		return nil, errors.New("ServerHello is missing compression_method")
+	}
+
+	if r.empty() {
+		// Extensions are optional.
+		return info, nil
+	}
+
+	extensions, ok := r.readUint16LengthPrefixed()
+	if !ok || !r.empty() {
+		return nil, errors.New("ServerHello has malformed extensions")
+	}
+
+	for !extensions.empty() {
+		extType, ok := extensions.readUint16()
+		if !ok {
+			return nil, errors.New("ServerHello has malformed extension header")
+		}
+
+		ext, ok := extensions.readUint16LengthPrefixed()
+		if !ok {
+			return nil, errors.New("ServerHello has malformed extension body")
+		}
+		_ = ext
+
+		info.Extensions = append(info.Extensions, extType)
+	}
+
+	return info, nil
+}
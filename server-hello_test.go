@@ -0,0 +1,67 @@
+// Copyright 2017 Tom Thorogood. All rights reserved.
+// Use of this source code is governed by a
+// Modified BSD License that can be found in
+// the LICENSE file.
+
+package tlsdebug
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// buildTestServerHello returns the body of a synthetic
+// ServerHello handshake message (as parseServerHello would
+// return it), with the given extension types.
+func buildTestServerHello(extTypes ...uint16) []byte {
+	u16 := func(v uint16) []byte {
+		b := make([]byte, 2)
+		binary.BigEndian.PutUint16(b, v)
+		return b
+	}
+
+	u16LengthPrefixed := func(data []byte) []byte {
+		return append(u16(uint16(len(data))), data...)
+	}
+
+	u8LengthPrefixed := func(data []byte) []byte {
+		return append([]byte{byte(len(data))}, data...)
+	}
+
+	var extensions bytes.Buffer
+	for _, typ := range extTypes {
+		extensions.Write(u16(typ))
+		extensions.Write(u16LengthPrefixed(nil))
+	}
+
+	var b bytes.Buffer
+	b.Write(u16(0x0304))           // server_version: TLS 1.3
+	b.Write(make([]byte, 32))      // random
+	b.Write(u8LengthPrefixed(nil)) // session_id_echo
+	b.Write(u16(0x1301))           // cipher_suite: TLS_AES_128_GCM_SHA256
+	b.Write([]byte{0})             // compression_method: null
+	b.Write(u16LengthPrefixed(extensions.Bytes()))
+	return b.Bytes()
+}
+
+func TestParseServerHelloInfo(t *testing.T) {
+	info, err := parseServerHelloInfo(buildTestServerHello(extKeyShare, extSupportedVersions))
+	if err != nil {
+		t.Fatalf("parseServerHelloInfo: %v", err)
+	}
+
+	if info.Version != 0x0304 {
+		t.Errorf("Version = %#x, want 0x0304", info.Version)
+	}
+
+	if info.CipherSuite != 0x1301 {
+		t.Errorf("CipherSuite = %#x, want 0x1301", info.CipherSuite)
+	}
+
+	wantExtensions := []uint16{extKeyShare, extSupportedVersions}
+	if !reflect.DeepEqual(info.Extensions, wantExtensions) {
+		t.Errorf("Extensions = %#x, want %#x", info.Extensions, wantExtensions)
+	}
+}
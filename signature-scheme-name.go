@@ -0,0 +1,34 @@
+// Copyright 2017 Tom Thorogood. All rights reserved.
+// Use of this source code is governed by a
+// Modified BSD License that can be found in
+// the LICENSE file.
+
+package tlsdebug
+
+import "crypto/tls"
+
+var signatureSchemeNames = map[tls.SignatureScheme]string{
+	// XXX: keep in sync with crypto/tls.
+	tls.PKCS1WithSHA256:        "PKCS1WithSHA256",
+	tls.PKCS1WithSHA384:        "PKCS1WithSHA384",
+	tls.PKCS1WithSHA512:        "PKCS1WithSHA512",
+	tls.PSSWithSHA256:          "PSSWithSHA256",
+	tls.PSSWithSHA384:          "PSSWithSHA384",
+	tls.PSSWithSHA512:          "PSSWithSHA512",
+	tls.ECDSAWithP256AndSHA256: "ECDSAWithP256AndSHA256",
+	tls.ECDSAWithP384AndSHA384: "ECDSAWithP384AndSHA384",
+	tls.ECDSAWithP521AndSHA512: "ECDSAWithP521AndSHA512",
+	tls.Ed25519:                "Ed25519",
+	tls.PKCS1WithSHA1:          "PKCS1WithSHA1",
+	tls.ECDSAWithSHA1:          "ECDSAWithSHA1",
+}
+
+// SignatureSchemeName returns a human readable name for a
+// TLS signature scheme, as offered in the
+// signature_algorithms extension or reported against a peer
+// certificate.
+//
+// It returns an empty string if the scheme is unknown.
+func SignatureSchemeName(scheme tls.SignatureScheme) string {
+	return signatureSchemeNames[scheme]
+}
@@ -0,0 +1,181 @@
+// Copyright 2017 Tom Thorogood. All rights reserved.
+// Use of this source code is governed by a
+// Modified BSD License that can be found in
+// the LICENSE file.
+
+package tlsdebug
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// SNIRouter returns a router that, once served, accepts
+// connections from ln, parses each one's ClientHello and hands
+// it to route to choose an upstream net.Conn. The buffered
+// ClientHello record, and every byte that follows it, is then
+// spliced bidirectionally between the client and the chosen
+// upstream.
+//
+// Unlike ClientHelloListener, the local process never
+// terminates TLS; it only inspects the ClientHello in order
+// to route the connection, in the spirit of ShadowTLS and
+// sniproxy style front-ends.
+//
+// If the ClientHello cannot be extracted, or route returns
+// an error or a nil net.Conn, the client connection is
+// closed.
+//
+// clientHelloTimeout bounds how long the router will wait for
+// a complete ClientHello to arrive before giving up on a
+// connection, so a client that opens a connection and never
+// completes (or drip-feeds) its ClientHello cannot hold the
+// connection, its goroutine and its bufferPool buffer open
+// indefinitely. Zero means no bound.
+//
+// Unlike a net.Listener, there is no way to obtain an accepted
+// net.Conn directly: every connection accepted from ln is
+// owned by the router for its entire lifetime, routed and
+// spliced on its own goroutine, so a caller cannot race the
+// router's own reads and writes against its own by following
+// the usual `c, _ := ln.Accept(); go handle(c)` idiom.
+func SNIRouter(ln net.Listener, clientHelloTimeout time.Duration, route func(*ClientHelloInfo) (net.Conn, error)) *SNIRouterServer {
+	return &SNIRouterServer{ln, clientHelloTimeout, route}
+}
+
+// SNIRouterServer is returned by SNIRouter. It is analogous to
+// *http.Server: call Serve to run it.
+type SNIRouterServer struct {
+	ln                 net.Listener
+	clientHelloTimeout time.Duration
+	route              func(*ClientHelloInfo) (net.Conn, error)
+}
+
+// Serve accepts connections from the underlying net.Listener
+// until it returns a non-nil error (including as a result of
+// Close being called), which Serve then returns.
+func (s *SNIRouterServer) Serve() error {
+	for {
+		c, err := s.ln.Accept()
+		if err != nil {
+			return err
+		}
+
+		go routeConn(c, s.clientHelloTimeout, s.route)
+	}
+}
+
+// Close closes the underlying net.Listener, causing Serve to
+// return. It does not close connections already accepted.
+func (s *SNIRouterServer) Close() error {
+	return s.ln.Close()
+}
+
+// routeConn extracts the ClientHello from c, routes it via
+// route, replays the buffered bytes to the chosen upstream
+// and splices the two connections together. c and the
+// upstream are always closed by the time routeConn returns.
+func routeConn(c net.Conn, clientHelloTimeout time.Duration, route func(*ClientHelloInfo) (net.Conn, error)) {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	buf.Grow(512 + 32)
+	defer bufferPool.Put(buf)
+
+	info, err := peekClientHello(c, buf, clientHelloTimeout)
+	if err != nil {
+		c.Close()
+		return
+	}
+
+	upstream, err := route(info)
+	if err != nil || upstream == nil {
+		c.Close()
+		return
+	}
+
+	if _, err := upstream.Write(buf.Bytes()); err != nil {
+		c.Close()
+		upstream.Close()
+		return
+	}
+
+	splice(c, upstream)
+}
+
+// peekClientHello reads from c, buffering into buf, until a
+// complete ClientHello handshake record has arrived, and
+// returns its parsed form. buf retains every byte read from
+// c so that it can be replayed to an upstream connection.
+//
+// If timeout is non-zero, c's read deadline is set to bound
+// the whole wait and cleared again before peekClientHello
+// returns.
+func peekClientHello(c net.Conn, buf *bytes.Buffer, timeout time.Duration) (*ClientHelloInfo, error) {
+	if timeout != 0 {
+		c.SetReadDeadline(time.Now().Add(timeout))
+		defer c.SetReadDeadline(time.Time{})
+	}
+
+	var tmp [4096]byte
+
+	for {
+		n, err := c.Read(tmp[:])
+		if n > 0 {
+			buf.Write(tmp[:n])
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		body, herr := handshakeRecord(buf.Bytes())
+		if herr == io.ErrUnexpectedEOF {
+			continue
+		}
+		if herr != nil {
+			return nil, herr
+		}
+
+		hb, herr := parseHello(body)
+		if herr != nil {
+			return nil, herr
+		}
+
+		return parseClientHelloInfo(hb)
+	}
+}
+
+// splice copies bytes bidirectionally between a and b until
+// both directions have finished (each side closed or returned
+// an error), then closes both.
+//
+// A direction finishing early, for example because the client
+// half-closed its write side after sending a request, does not
+// tear down the other, still in-flight direction: splice
+// half-closes the destination of the finished direction, if it
+// supports CloseWrite, and waits for the remaining direction to
+// finish on its own before closing both connections fully.
+func splice(a, b net.Conn) {
+	defer a.Close()
+	defer b.Close()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	copyAndCloseWrite := func(dst, src net.Conn) {
+		defer wg.Done()
+
+		io.Copy(dst, src)
+
+		if cw, ok := dst.(interface{ CloseWrite() error }); ok {
+			cw.CloseWrite()
+		}
+	}
+
+	go copyAndCloseWrite(b, a)
+	go copyAndCloseWrite(a, b)
+
+	wg.Wait()
+}
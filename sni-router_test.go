@@ -0,0 +1,109 @@
+// Copyright 2017 Tom Thorogood. All rights reserved.
+// Use of this source code is governed by a
+// Modified BSD License that can be found in
+// the LICENSE file.
+
+package tlsdebug
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// clientHelloRecord wraps a ClientHello handshake body (as
+// returned by buildTestClientHello) in its handshake message
+// header and TLS record header, ready to be written to a raw
+// net.Conn.
+func clientHelloRecord(body []byte) []byte {
+	msg := make([]byte, 4+len(body))
+	msg[0] = 1 // ClientHello
+	msg[1] = byte(len(body) >> 16)
+	msg[2] = byte(len(body) >> 8)
+	msg[3] = byte(len(body))
+	copy(msg[4:], body)
+
+	record := make([]byte, 5+len(msg))
+	record[0] = 22 // handshake
+	binary.BigEndian.PutUint16(record[1:3], 0x0301)
+	binary.BigEndian.PutUint16(record[3:5], uint16(len(msg)))
+	copy(record[5:], msg)
+	return record
+}
+
+// TestSNIRouterHalfCloseDoesNotTruncateResponse guards against
+// splice tearing down the upstream connection as soon as the
+// client half-closes, before the upstream's in-flight response
+// has been forwarded back to the client.
+func TestSNIRouterHalfCloseDoesNotTruncateResponse(t *testing.T) {
+	tcpLn, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen: %v", err)
+	}
+	defer tcpLn.Close()
+
+	upstreamRouterEnd, upstreamTestEnd := net.Pipe()
+
+	router := SNIRouter(tcpLn, 5*time.Second, func(info *ClientHelloInfo) (net.Conn, error) {
+		if info.ServerName != "example.com" {
+			t.Errorf("ServerName = %q, want %q", info.ServerName, "example.com")
+		}
+		return upstreamRouterEnd, nil
+	})
+	defer router.Close()
+
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- router.Serve() }()
+
+	client, err := net.Dial("tcp", tcpLn.Addr().String())
+	if err != nil {
+		t.Fatalf("net.Dial: %v", err)
+	}
+	defer client.Close()
+
+	const request = "GET / extra request bytes"
+
+	if _, err := client.Write(clientHelloRecord(buildTestClientHello(nil))); err != nil {
+		t.Fatalf("write ClientHello: %v", err)
+	}
+	if _, err := client.Write([]byte(request)); err != nil {
+		t.Fatalf("write request: %v", err)
+	}
+	if err := client.(*net.TCPConn).CloseWrite(); err != nil {
+		t.Fatalf("CloseWrite: %v", err)
+	}
+
+	got := make([]byte, len(clientHelloRecord(buildTestClientHello(nil)))+len(request))
+	if _, err := io.ReadFull(upstreamTestEnd, got); err != nil {
+		t.Fatalf("read forwarded request: %v", err)
+	}
+	if string(got[len(got)-len(request):]) != request {
+		t.Fatalf("forwarded request = %q, want it to end with %q", got, request)
+	}
+
+	const response = "HTTP/1.1 200 OK\r\n\r\nhello"
+
+	if _, err := upstreamTestEnd.Write([]byte(response)); err != nil {
+		t.Fatalf("write response: %v", err)
+	}
+	if err := upstreamTestEnd.Close(); err != nil {
+		t.Fatalf("close upstream: %v", err)
+	}
+
+	gotResponse, err := io.ReadAll(client)
+	if err != nil {
+		t.Fatalf("read response: %v", err)
+	}
+	if string(gotResponse) != response {
+		t.Fatalf("response = %q, want %q (response truncated by premature close)", gotResponse, response)
+	}
+
+	router.Close()
+	select {
+	case <-serveErr:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for Serve to return after Close")
+	}
+}
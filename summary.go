@@ -0,0 +1,72 @@
+// Copyright 2017 Tom Thorogood. All rights reserved.
+// Use of this source code is governed by a
+// Modified BSD License that can be found in
+// the LICENSE file.
+
+package tlsdebug
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+)
+
+// Summary renders the headline parameters of a completed
+// handshake, as reported by cs, as a single human readable
+// line suitable for logging from a TLSHandshakeFunc.
+//
+// It includes the negotiated version, cipher suite, ALPN
+// protocol, SNI, key exchange curve, the leaf peer
+// certificate's signature algorithm, and whether an OCSP
+// response or SCTs were stapled.
+func Summary(cs *tls.ConnectionState) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "version=%s cipher=%s", versionOrHex(cs.Version), cipherOrHex(cs.CipherSuite))
+
+	if cs.NegotiatedProtocol != "" {
+		fmt.Fprintf(&b, " alpn=%s", cs.NegotiatedProtocol)
+	}
+
+	if cs.ServerName != "" {
+		fmt.Fprintf(&b, " sni=%s", cs.ServerName)
+	}
+
+	if cs.CurveID != 0 {
+		curve := CurveName(cs.CurveID)
+		if curve == "" {
+			curve = fmt.Sprintf("0x%04x", uint16(cs.CurveID))
+		}
+		fmt.Fprintf(&b, " curve=%s", curve)
+	}
+
+	if len(cs.PeerCertificates) > 0 {
+		fmt.Fprintf(&b, " peer-sig=%s", cs.PeerCertificates[0].SignatureAlgorithm)
+	}
+
+	fmt.Fprintf(&b, " ocsp-stapled=%t sct-stapled=%t",
+		len(cs.OCSPResponse) > 0, len(cs.SignedCertificateTimestamps) > 0)
+
+	return b.String()
+}
+
+// versionOrHex returns VersionName(vers), falling back to a
+// hex encoding of vers if it is unrecognised.
+func versionOrHex(vers uint16) string {
+	if name := VersionName(vers); name != "" {
+		return name
+	}
+
+	return fmt.Sprintf("0x%04x", vers)
+}
+
+// cipherOrHex returns the name of the cipher suite
+// identified by id, falling back to a hex encoding of id if
+// it is unrecognised.
+func cipherOrHex(id uint16) string {
+	if cs := CipherSuiteByID(id); cs != nil {
+		return cs.Name()
+	}
+
+	return fmt.Sprintf("0x%04x", id)
+}
@@ -6,70 +6,200 @@
 package tlsdebug
 
 import (
+	"context"
 	"crypto/tls"
+	"errors"
 	"net"
-	"net/http"
 	"time"
 )
 
 // TLSHandshakeFunc is a function to be called after a TLS handshake
-// has been performed. It takes the underlying net.Conn, a
+// has been attempted. It takes the underlying net.Conn, a
 // *tls.ConnectionState and any error returned from
-// (*tls.Conn).Handshake.
+// (*tls.Conn).HandshakeContext.
 //
 // conn MUST NOT be written to or read from, doing so will
 // cause the connection to fail. cs will never be nil.
 type TLSHandshakeFunc func(conn net.Conn, cs *tls.ConnectionState, err error)
 
-// HTTPHandshakeListener wraps a given net.Listener. On calls to Accept
-// it performs the TLS handshake and subsequently invokes fn.
+// ErrorHandler is called in place of a TLSHandshakeFunc when
+// a connection cannot be handshaken at all: because the
+// wrapped net.Listener yielded a net.Conn that is not a
+// *tls.Conn, or because a handshake could not be started
+// before HandshakeListenerConfig.IdleTimeout elapsed or the
+// listener's context was done.
+type ErrorHandler func(conn net.Conn, err error)
+
+// defaultMaxConcurrentHandshakes bounds the number of TLS
+// handshakes a handshake listener performs concurrently when
+// HandshakeListenerConfig.MaxConcurrent is left unset.
+const defaultMaxConcurrentHandshakes = 128
+
+// HandshakeListenerConfig configures the handshake listener
+// returned by HTTPHandshakeListener.
+type HandshakeListenerConfig struct {
+	// MaxConcurrent bounds the number of connections that may
+	// be mid-handshake at once. Accept withholds any further
+	// accepted connection, leaving it unhandshaken and unread
+	// by any consumer, until a slot frees up, so that a flood
+	// of half-open TLS connections cannot starve the rest of
+	// the process. It defaults to defaultMaxConcurrentHandshakes
+	// if zero or negative.
+	MaxConcurrent int
+
+	// HandshakeTimeout bounds how long a single handshake may
+	// take once it has acquired a worker slot. Zero means no
+	// bound.
+	HandshakeTimeout time.Duration
+
+	// IdleTimeout bounds how long Accept will block a given
+	// connection waiting for a free worker slot before giving
+	// up on it. Zero means no bound.
+	IdleTimeout time.Duration
+
+	// OnHandshake is called once a connection's handshake
+	// completes, successfully or not. A nil OnHandshake is
+	// replaced with a no-op.
+	OnHandshake TLSHandshakeFunc
+
+	// OnError is called, instead of OnHandshake, when a
+	// connection cannot be handshaken at all. A nil OnError is
+	// replaced with a no-op.
+	OnError ErrorHandler
+}
+
+// HTTPHandshakeListener wraps ln so that Accept only ever
+// hands out as many connections as cfg.MaxConcurrent permits
+// to be mid-handshake at once, and cfg.OnHandshake (or
+// cfg.OnError) is invoked with the result of each one.
+//
+// This bound has to be enforced in Accept itself, before the
+// connection is returned to the caller: net/http's
+// (*http.conn).serve type-asserts an accepted net.Conn to
+// *tls.Conn and drives its own unthrottled HandshakeContext
+// call on a goroutine it spawns immediately on accept, with no
+// relation to cfg.MaxConcurrent. Anything done to the
+// connection only after Accept returns it (for example
+// handshaking it from a background goroutine there) would just
+// race net/http's own handshake rather than bound it, so
+// instead Accept withholds each accepted connection until a
+// worker slot frees up (subject to cfg.IdleTimeout), starts
+// its handshake itself, and only then returns it; net/http's
+// own HandshakeContext call, if any, simply observes the
+// now-in-progress or already-completed result via
+// (*tls.Conn)'s handshake mutex, which is safe to call
+// concurrently and idempotent once the handshake has
+// completed.
+//
+// Accept never panics: if ln yields a net.Conn that is not a
+// *tls.Conn, it is returned unchanged and reported via
+// cfg.OnError rather than handshaken.
+//
+// Accept returns the accepted *tls.Conn unmodified, so
+// net/http's own handling of it (populating (*http.Request).TLS,
+// dispatching TLSNextProto for ALPN-negotiated protocols such
+// as HTTP/2) keeps working exactly as it does with a plain
+// tls.NewListener.
 //
-// The *http.Server's ReadTimeout and WriteTimeout are used to cover
-// the TLS handshake. This mirrors the standard timeout behaviour of
-// (*http.Server).Serve.
+// A connection that cannot acquire a worker slot within
+// cfg.IdleTimeout is closed and reported via cfg.OnError
+// instead of being returned, and Accept moves on to the next
+// one from ln; it never hands back a connection whose
+// handshake was never started.
 //
-// The returned net.Listener's Accept will panic if the net.Conn is not
-// a *tls.Conn. ln should be a the return value of tls.NewListener.
-func HTTPHandshakeListener(ln net.Listener, srv *http.Server, fn TLSHandshakeFunc) net.Listener {
+// ctx bounds the lifetime of every handshake started by the
+// returned listener; cancelling it aborts in-flight
+// handshakes and causes connections still waiting for a worker
+// slot to be closed and reported via cfg.OnError instead of
+// being returned. It does not close ln or any accepted
+// connection.
+func HTTPHandshakeListener(ctx context.Context, ln net.Listener, cfg HandshakeListenerConfig) net.Listener {
+	if cfg.MaxConcurrent <= 0 {
+		cfg.MaxConcurrent = defaultMaxConcurrentHandshakes
+	}
+	if cfg.OnHandshake == nil {
+		cfg.OnHandshake = func(net.Conn, *tls.ConnectionState, error) {}
+	}
+	if cfg.OnError == nil {
+		cfg.OnError = func(net.Conn, error) {}
+	}
+
 	return &httpHandshakeListener{
-		ln, fn,
-		srv.ReadTimeout,
-		srv.WriteTimeout,
+		Listener: ln,
+		ctx:      ctx,
+		cfg:      cfg,
+		sem:      make(chan struct{}, cfg.MaxConcurrent),
 	}
 }
 
 type httpHandshakeListener struct {
 	net.Listener
-	fn           TLSHandshakeFunc
-	readTimeout  time.Duration
-	writeTimeout time.Duration
+	ctx context.Context
+	cfg HandshakeListenerConfig
+	sem chan struct{}
 }
 
 func (ln *httpHandshakeListener) Accept() (net.Conn, error) {
-	c, err := ln.Listener.Accept()
-	if err != nil {
-		return nil, err
+	for {
+		c, err := ln.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		tc, ok := c.(*tls.Conn)
+		if !ok {
+			ln.cfg.OnError(c, errors.New("tlsdebug: Accept did not return a *tls.Conn"))
+			return c, nil
+		}
+
+		if !ln.acquire(tc) {
+			continue
+		}
+
+		go ln.handshake(tc)
+
+		return tc, nil
 	}
+}
 
-	tc, ok := c.(*tls.Conn)
-	if !ok {
-		panic("tlsdebug.httpHandshakeListener: Accept did not return *tls.Conn")
+// acquire blocks until a worker slot is free, subject to
+// ln.cfg.IdleTimeout, and reports true once it has claimed
+// one. If the wait times out or ln.ctx is done first, it
+// closes tc, reports the failure via ln.cfg.OnError and
+// reports false.
+func (ln *httpHandshakeListener) acquire(tc *tls.Conn) bool {
+	waitCtx := ln.ctx
+	if ln.cfg.IdleTimeout != 0 {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithTimeout(waitCtx, ln.cfg.IdleTimeout)
+		defer cancel()
 	}
 
-	if ln.readTimeout != 0 || ln.writeTimeout != 0 {
-		now := time.Now()
+	select {
+	case ln.sem <- struct{}{}:
+		return true
+	case <-waitCtx.Done():
+		ln.cfg.OnError(tc, waitCtx.Err())
+		tc.Close()
+		return false
+	}
+}
 
-		if ln.readTimeout != 0 {
-			tc.SetReadDeadline(now.Add(ln.readTimeout))
-		}
+// handshake performs tc's handshake, subject to
+// ln.cfg.HandshakeTimeout, reports the result, successful or
+// not, via ln.cfg.OnHandshake, and releases the worker slot
+// acquired for tc by acquire.
+func (ln *httpHandshakeListener) handshake(tc *tls.Conn) {
+	defer func() { <-ln.sem }()
 
-		if ln.writeTimeout != 0 {
-			tc.SetWriteDeadline(now.Add(ln.writeTimeout))
-		}
+	handshakeCtx := ln.ctx
+	if ln.cfg.HandshakeTimeout != 0 {
+		var cancel context.CancelFunc
+		handshakeCtx, cancel = context.WithTimeout(handshakeCtx, ln.cfg.HandshakeTimeout)
+		defer cancel()
 	}
 
-	err = tc.Handshake()
+	err := tc.HandshakeContext(handshakeCtx)
 	cs := tc.ConnectionState()
-	ln.fn(c, &cs, err)
-	return c, nil
+	ln.cfg.OnHandshake(tc, &cs, err)
 }
@@ -0,0 +1,227 @@
+// Copyright 2017 Tom Thorogood. All rights reserved.
+// Use of this source code is governed by a
+// Modified BSD License that can be found in
+// the LICENSE file.
+
+package tlsdebug
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// generateTestCertificate returns a minimal self-signed
+// certificate, for use as a tls.Config.Certificates entry in
+// tests.
+func generateTestCertificate(t *testing.T) tls.Certificate {
+	t.Helper()
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey: %v", err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "tlsdebug test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &priv.PublicKey, priv)
+	if err != nil {
+		t.Fatalf("x509.CreateCertificate: %v", err)
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: priv}
+}
+
+func TestHTTPHandshakeListenerHandshake(t *testing.T) {
+	tcpLn, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen: %v", err)
+	}
+	defer tcpLn.Close()
+
+	cert := generateTestCertificate(t)
+	tlsLn := tls.NewListener(tcpLn, &tls.Config{Certificates: []tls.Certificate{cert}})
+
+	type result struct {
+		cs  *tls.ConnectionState
+		err error
+	}
+	results := make(chan result, 1)
+
+	ln := HTTPHandshakeListener(context.Background(), tlsLn, HandshakeListenerConfig{
+		OnHandshake: func(conn net.Conn, cs *tls.ConnectionState, err error) {
+			results <- result{cs, err}
+		},
+		OnError: func(conn net.Conn, err error) {
+			t.Errorf("OnError: %v", err)
+		},
+	})
+	defer ln.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			t.Errorf("Accept: %v", err)
+			return
+		}
+		accepted <- c
+	}()
+
+	clientConn, err := tls.Dial("tcp", tcpLn.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatalf("tls.Dial: %v", err)
+	}
+	defer clientConn.Close()
+	defer func() { (<-accepted).Close() }()
+
+	select {
+	case r := <-results:
+		if r.err != nil {
+			t.Errorf("handshake error = %v, want nil", r.err)
+		}
+		if r.cs == nil {
+			t.Fatal("ConnectionState = nil, want non-nil")
+		}
+		if !r.cs.HandshakeComplete {
+			t.Error("ConnectionState.HandshakeComplete = false, want true")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for OnHandshake")
+	}
+}
+
+func TestHTTPHandshakeListenerNonTLSConn(t *testing.T) {
+	tcpLn, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen: %v", err)
+	}
+	defer tcpLn.Close()
+
+	errs := make(chan error, 1)
+
+	ln := HTTPHandshakeListener(context.Background(), tcpLn, HandshakeListenerConfig{
+		OnError: func(conn net.Conn, err error) {
+			errs <- err
+		},
+	})
+	defer ln.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			t.Errorf("Accept: %v", err)
+			return
+		}
+		accepted <- c
+	}()
+
+	clientConn, err := net.Dial("tcp", tcpLn.Addr().String())
+	if err != nil {
+		t.Fatalf("net.Dial: %v", err)
+	}
+	defer clientConn.Close()
+
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Error("OnError called with nil error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for OnError")
+	}
+
+	select {
+	case c := <-accepted:
+		c.Close()
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for Accept to return the non-TLS conn")
+	}
+}
+
+// TestHTTPHandshakeListenerMaxConcurrent guards against
+// Accept handing out more connections than
+// HandshakeListenerConfig.MaxConcurrent permits to be
+// mid-handshake at once: it uses tls.Config.GetConfigForClient,
+// which crypto/tls calls synchronously from within the
+// handshake itself, to observe how many handshakes are
+// actually running concurrently.
+func TestHTTPHandshakeListenerMaxConcurrent(t *testing.T) {
+	tcpLn, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen: %v", err)
+	}
+	defer tcpLn.Close()
+
+	cert := generateTestCertificate(t)
+
+	var current, max int64
+	cfg := &tls.Config{
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			n := atomic.AddInt64(&current, 1)
+			for {
+				m := atomic.LoadInt64(&max)
+				if n <= m || atomic.CompareAndSwapInt64(&max, m, n) {
+					break
+				}
+			}
+			time.Sleep(50 * time.Millisecond)
+			atomic.AddInt64(&current, -1)
+			return nil, nil
+		},
+		Certificates: []tls.Certificate{cert},
+	}
+	tlsLn := tls.NewListener(tcpLn, cfg)
+
+	ln := HTTPHandshakeListener(context.Background(), tlsLn, HandshakeListenerConfig{
+		MaxConcurrent: 1,
+	})
+	defer ln.Close()
+
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go c.Close()
+		}
+	}()
+
+	const clients = 3
+	done := make(chan struct{}, clients)
+	for i := 0; i < clients; i++ {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			c, err := tls.Dial("tcp", tcpLn.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+			if err != nil {
+				return
+			}
+			c.Close()
+		}()
+	}
+	for i := 0; i < clients; i++ {
+		<-done
+	}
+
+	if got := atomic.LoadInt64(&max); got > 1 {
+		t.Errorf("observed %d concurrent handshakes, want at most MaxConcurrent = 1", got)
+	}
+}
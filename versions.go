@@ -5,18 +5,51 @@
 
 package tlsdebug
 
-import "crypto/tls"
+import (
+	"crypto/tls"
+	"fmt"
+)
 
 // TODO: remove once TLS 1.3 support is in all supported
 // golang versions.
 const tls_VersionTLS13 = 0x0304
 
+// draftVersionMask and draftVersionMagic identify the
+// 0x7f00|draft codepoints used by TLS 1.3 drafts 18 through
+// 28, per the draft-ietf-tls-tls13 series.
+const (
+	draftVersionMask  = 0xff00
+	draftVersionMagic = 0x7f00
+)
+
+// facebookDraftVersions are the experimental codepoints used
+// by Facebook's pre-standard TLS 1.3 deployment.
+var facebookDraftVersions = map[uint16]bool{
+	0xfb17: true,
+	0xfb1a: true,
+}
+
 // VersionName returns a human readable name associated
-// with a given TLS version code.
+// with a given TLS version code. This includes the
+// 0x7f00|draft codepoints used by TLS 1.3 drafts and the
+// experimental codepoints used by Facebook's pre-standard
+// TLS 1.3 deployment.
 //
 // It returns an empty string if the version is unknown.
 func VersionName(vers uint16) string {
-	return versionToName[vers]
+	if name, ok := versionToName[vers]; ok {
+		return name
+	}
+
+	if vers&draftVersionMask == draftVersionMagic {
+		return fmt.Sprintf("TLS 1.3 (draft %d)", vers&0xff)
+	}
+
+	if facebookDraftVersions[vers] {
+		return "TLS 1.3 (Facebook draft)"
+	}
+
+	return ""
 }
 
 var versionToName = map[uint16]string{
@@ -27,3 +60,14 @@ var versionToName = map[uint16]string{
 	tls.VersionTLS12: "TLS 1.2",
 	tls_VersionTLS13: "TLS 1.3",
 }
+
+// SupportedVersions returns every TLS version advertised by
+// ch: the contents of its supported_versions extension if
+// present, or its legacy client_version field otherwise.
+func SupportedVersions(ch *ClientHelloInfo) []uint16 {
+	if len(ch.SupportedVersions) > 0 {
+		return ch.SupportedVersions
+	}
+
+	return []uint16{ch.Version}
+}